@@ -0,0 +1,70 @@
+// Copyright 2023 Upbound Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Command migrator applies a generated Configuration migration plan against
+// a live cluster.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"sigs.k8s.io/yaml"
+
+	"github.com/sergenyalcin/extensions-migration/pkg/executor"
+)
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	if len(args) == 0 || args[0] != "run" {
+		return fmt.Errorf("usage: migrator run [--kubeconfig path] [--context name] <plan.yaml>")
+	}
+
+	fs := flag.NewFlagSet("run", flag.ExitOnError)
+	kubeconfig := fs.String("kubeconfig", "", "path to the kubeconfig file to use, defaults to kubectl's own resolution")
+	kubeContext := fs.String("context", "", "kubeconfig context to use, defaults to kubectl's current context")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: migrator run [--kubeconfig path] [--context name] <plan.yaml>")
+	}
+
+	data, err := os.ReadFile(fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("cannot read plan: %w", err)
+	}
+	var plan executor.Plan
+	if err := yaml.Unmarshal(data, &plan); err != nil {
+		return fmt.Errorf("cannot parse plan: %w", err)
+	}
+
+	e := executor.NewKubectlExecutor(*kubeconfig, *kubeContext)
+	results, err := executor.Run(context.Background(), e, plan)
+	for _, r := range results {
+		fmt.Println(r)
+	}
+	if err != nil {
+		return fmt.Errorf("migration failed: %w", err)
+	}
+	return nil
+}
@@ -29,35 +29,33 @@ import (
 	"github.com/upbound/upjet/pkg/migration"
 )
 
-const (
-	awsPackage   = "xpkg.upbound.io/upbound/provider-aws"
-	azurePackage = "xpkg.upbound.io/upbound/provider-azure"
-	gcpPackage   = "xpkg.upbound.io/upbound/provider-gcp"
-)
-
 type mRPreProcessor struct {
+	Registry      *RegistryConfig
 	ProviderNames map[string]struct{}
 }
 
-func NewMRPreProcessor() *mRPreProcessor {
+func NewMRPreProcessor(registry *RegistryConfig) *mRPreProcessor {
 	return &mRPreProcessor{
+		Registry:      registry,
 		ProviderNames: map[string]struct{}{},
 	}
 }
 
 type compositionPreProcessor struct {
+	Registry      *RegistryConfig
 	ProviderNames map[string]struct{}
 }
 
-func NewCompositionPreProcessor() *compositionPreProcessor {
+func NewCompositionPreProcessor(registry *RegistryConfig) *compositionPreProcessor {
 	return &compositionPreProcessor{
+		Registry:      registry,
 		ProviderNames: map[string]struct{}{},
 	}
 }
 
 // GetSSOPNameFromManagedResource collects the new provider name from MR
 func (mp *mRPreProcessor) GetSSOPNameFromManagedResource(u migration.UnstructuredWithMetadata) error {
-	for _, pn := range getProviderAndServiceName(u.Object.GroupVersionKind().Group) {
+	for _, pn := range getProviderAndServiceName(u.Object.GroupVersionKind().Group, mp.Registry) {
 		mp.ProviderNames[pn] = struct{}{}
 	}
 	return nil
@@ -74,54 +72,96 @@ func (cp *compositionPreProcessor) GetSSOPNameFromComposition(u migration.Unstru
 		if err != nil {
 			return errors.Wrap(err, "resource raw cannot convert to unstructured")
 		}
-		for _, pn := range getProviderAndServiceName(composedUnstructured.GroupVersionKind().Group) {
+		for _, pn := range getProviderAndServiceName(composedUnstructured.GroupVersionKind().Group, cp.Registry) {
 			cp.ProviderNames[pn] = struct{}{}
 		}
 	}
 	return nil
 }
 
-func getProviderAndServiceName(name string) []string {
+// getProviderAndServiceName derives the service-scoped and family provider
+// names for the given API group, e.g. "ec2.aws.upbound.io" yields
+// "provider-aws-ec2" and "provider-family-aws". The family name is taken
+// from registry's MonolithMapping when the group suffix is recognized, and
+// falls back to the "provider-family-<suffix>" convention otherwise, so
+// providers that haven't been explicitly registered still migrate.
+func getProviderAndServiceName(name string, registry *RegistryConfig) []string {
 	parts := strings.Split(name, ".")
 	switch len(parts) {
 	case 4:
-		return []string{fmt.Sprintf("provider-%s-%s", parts[1], parts[0]), fmt.Sprintf("provider-family-%s", parts[1])}
+		return []string{fmt.Sprintf("provider-%s-%s", parts[1], parts[0]), familyName(parts[1], registry)}
 	case 3:
-		return []string{fmt.Sprintf("provider-family-%s", parts[0])}
+		return []string{familyName(parts[0], registry)}
 	default:
 		return nil
 	}
 }
 
+func familyName(groupSuffix string, registry *RegistryConfig) string {
+	if m, ok := registry.MonolithByGroupSuffix(groupSuffix); ok {
+		return m.FamilyName
+	}
+	return fmt.Sprintf("provider-family-%s", groupSuffix)
+}
+
+// familyNameForMonolith derives the family name for a monolithic provider's
+// package name, e.g. "provider-foo" yields "provider-family-foo" for a
+// monolith that isn't registered in registry.Monoliths. It's the
+// package-name-keyed counterpart to familyName, which is keyed by group
+// suffix instead; the two must agree on the same fallback convention so a
+// provider's synthesized family name is recognized consistently by
+// RegistryConfig.IsFamilyName wherever it's produced.
+func familyNameForMonolith(monolithPackage string, registry *RegistryConfig) string {
+	if m, ok := registry.MonolithByPackage(monolithPackage); ok {
+		return m.FamilyName
+	}
+	return fmt.Sprintf("provider-family-%s", strings.TrimPrefix(monolithPackage, "provider-"))
+}
+
 type ConfigMetaParameters struct {
-	FamilyVersion        string
+	Registry             *RegistryConfig
+	Resolver             VersionResolver
 	Monolith             string
 	CompositionProcessor *compositionPreProcessor
 }
 
+// ConfigPkgParameters configures how the on-cluster Configuration package
+// itself is edited over the course of a migration.
 type ConfigPkgParameters struct {
 	PackageURL string
 }
 
-type LockParameters struct{}
+// LockParameters configures which monolithic providers are stripped from
+// the package Lock.
+type LockParameters struct {
+	Registry *RegistryConfig
+}
 
+// ConfigurationMetadataV1 rewrites the Configuration's dependsOn to depend on
+// the service-scoped providers instead of the monolith. It corresponds to
+// stepEditConfigurationMetadata and runs against the package sources, ahead
+// of repackaging and pushing the new Configuration.
 func (cm *ConfigMetaParameters) ConfigurationMetadataV1(c *xpmetav1.Configuration) error {
 	var convertedList []xpmetav1.Dependency
 
 	for _, provider := range c.Spec.DependsOn {
-		if *provider.Provider == fmt.Sprintf("xpkg.upbound.io/upbound/%s", cm.Monolith) {
+		if *provider.Provider == cm.Registry.PackageURL(cm.Monolith) {
 			continue
 		}
 		convertedList = append(convertedList, provider)
 	}
 
 	for providerName := range cm.CompositionProcessor.ProviderNames {
-		if strings.HasPrefix(providerName, "provider-family-") {
+		if cm.Registry.IsFamilyName(providerName) {
 			continue
 		}
+		version, err := cm.Resolver.Resolve(providerName)
+		if err != nil {
+			return errors.Wrapf(err, "cannot resolve version for %q", providerName)
+		}
 		dependency := xpmetav1.Dependency{
-			Provider: ptrFromString(fmt.Sprintf("xpkg.upbound.io/upbound/%s", providerName)),
-			Version:  fmt.Sprintf(">=%s", cm.FamilyVersion),
+			Provider: ptrFromString(cm.Registry.PackageURL(providerName)),
+			Version:  fmt.Sprintf(">=%s", version),
 		}
 		convertedList = append(convertedList, dependency)
 	}
@@ -130,23 +170,30 @@ func (cm *ConfigMetaParameters) ConfigurationMetadataV1(c *xpmetav1.Configuratio
 	return nil
 }
 
+// ConfigurationMetadataV1Alpha1 is the v1alpha1 equivalent of
+// ConfigurationMetadataV1 and corresponds to the same
+// stepEditConfigurationMetadata step.
 func (cm *ConfigMetaParameters) ConfigurationMetadataV1Alpha1(c *xpmetav1alpha1.Configuration) error {
 	var convertedList []xpmetav1alpha1.Dependency
 
 	for _, provider := range c.Spec.DependsOn {
-		if *provider.Provider == fmt.Sprintf("xpkg.upbound.io/upbound/%s", cm.Monolith) {
+		if *provider.Provider == cm.Registry.PackageURL(cm.Monolith) {
 			continue
 		}
 		convertedList = append(convertedList, provider)
 	}
 
 	for providerName := range cm.CompositionProcessor.ProviderNames {
-		if strings.HasPrefix(providerName, "provider-family-") {
+		if cm.Registry.IsFamilyName(providerName) {
 			continue
 		}
+		version, err := cm.Resolver.Resolve(providerName)
+		if err != nil {
+			return errors.Wrapf(err, "cannot resolve version for %q", providerName)
+		}
 		dependency := xpmetav1alpha1.Dependency{
-			Provider: ptrFromString(fmt.Sprintf("xpkg.upbound.io/upbound/%s", providerName)),
-			Version:  fmt.Sprintf(">=%s", cm.FamilyVersion),
+			Provider: ptrFromString(cm.Registry.PackageURL(providerName)),
+			Version:  fmt.Sprintf(">=%s", version),
 		}
 		convertedList = append(convertedList, dependency)
 	}
@@ -155,15 +202,49 @@ func (cm *ConfigMetaParameters) ConfigurationMetadataV1Alpha1(c *xpmetav1alpha1.
 	return nil
 }
 
+// ConfigurationPackageV1 points spec.package at the new, service-scoped
+// package URL. It corresponds to stepEditConfigurationPackage and must only
+// run once the new package has been repackaged and pushed to the registry.
 func (cp *ConfigPkgParameters) ConfigurationPackageV1(pkg *xppkgv1.Configuration) error {
 	pkg.Spec.Package = cp.PackageURL
 	return nil
 }
 
+// DisableDependencyResolution patches the Configuration package so the
+// package manager leaves the monolithic provider alone while the migration
+// is in progress. It corresponds to stepConfigurationPackageDisableDepResolution;
+// see Step for why it must run before the Lock is edited or the monolithic
+// Provider is deleted.
+func (cp *ConfigPkgParameters) DisableDependencyResolution(pkg *xppkgv1.Configuration) error {
+	return patchSkipDependencyResolution(pkg, true)
+}
+
+// EnableDependencyResolution re-enables dependency resolution for the
+// Configuration package once the monolithic provider has been removed from
+// the Lock. It corresponds to stepConfigurationPackageEnableDepResolution
+// and must be the last step applied in a migration.
+func (cp *ConfigPkgParameters) EnableDependencyResolution(pkg *xppkgv1.Configuration) error {
+	return patchSkipDependencyResolution(pkg, false)
+}
+
+// patchSkipDependencyResolution implements stepPatchSkipDependencyResolution,
+// shared by DisableDependencyResolution and EnableDependencyResolution.
+func patchSkipDependencyResolution(pkg *xppkgv1.Configuration, skip bool) error {
+	ap := xppkgv1.ManualActivation
+	pkg.Spec.SkipDependencyResolution = &skip
+	pkg.Spec.RevisionActivationPolicy = &ap
+	return nil
+}
+
+// PackageLockV1Beta1 strips the monolithic provider's entries from the
+// package Lock so it can't be re-resolved once dependency resolution is
+// re-enabled. It corresponds to the Lock-editing half of
+// stepDeleteMonolithicProvider and must run before the monolithic Provider
+// itself is deleted.
 func (l *LockParameters) PackageLockV1Beta1(lock *xppkgv1beta1.Lock) error {
 	packages := make([]xppkgv1beta1.LockPackage, 0, len(lock.Packages))
 	for _, lp := range lock.Packages {
-		if lp.Source != awsPackage && lp.Source != azurePackage && lp.Source != gcpPackage {
+		if !l.isMonolith(lp.Source) {
 			packages = append(packages, lp)
 		}
 	}
@@ -171,59 +252,144 @@ func (l *LockParameters) PackageLockV1Beta1(lock *xppkgv1beta1.Lock) error {
 	return nil
 }
 
+func (l *LockParameters) isMonolith(source string) bool {
+	for _, m := range l.Registry.Monoliths {
+		if source == l.Registry.PackageURL(m.MonolithPackage) {
+			return true
+		}
+	}
+	return false
+}
+
+// ProviderRevisionActivationParameters activates the ProviderRevisions of
+// the service-scoped Providers created earlier in the migration, once they
+// have installed successfully.
+type ProviderRevisionActivationParameters struct {
+	// ServiceScopedProviders holds the names of the service-scoped Provider
+	// packages, e.g. "provider-aws-s3", whose current ProviderRevision
+	// should be activated.
+	ServiceScopedProviders map[string]struct{}
+}
+
+// ProviderRevisionV1 activates the ProviderRevision if it belongs to one of
+// the service-scoped Providers created during this migration. It corresponds
+// to stepActivateServiceScopedProviderRevision and must run after the
+// revision has reported Healthy and Installed.
+func (ra *ProviderRevisionActivationParameters) ProviderRevisionV1(pr xppkgv1.ProviderRevision) (xppkgv1.ProviderRevision, error) {
+	if _, ok := ra.ServiceScopedProviders[pr.GetLabels()["pkg.crossplane.io/provider"]]; ok {
+		pr.Spec.DesiredState = xppkgv1.PackageRevisionActive
+	}
+	return pr, nil
+}
+
 type ProviderPkgFamilyConfigParameters struct {
-	FamilyVersion string
+	Registry *RegistryConfig
+	Resolver VersionResolver
 }
 
+// ProviderPackageV1 creates the family Provider with Manual activation. It
+// is part of stepConfigurationPackageDisableDepResolution: the family
+// Provider must exist, with its revision unactivated, before the Lock is
+// edited and the monolith is deleted.
 func (pc *ProviderPkgFamilyConfigParameters) ProviderPackageV1(s xppkgv1.Provider) ([]xppkgv1.Provider, error) {
 	ap := xppkgv1.ManualActivation
-	provider := extractProviderNameFromPackageName(s.Spec.PackageSpec.Package)
-	switch provider {
-	case "provider-aws":
-		provider = "provider-family-aws"
-	case "provider-gcp":
-		provider = "provider-family-gcp"
-	case "provider-azure":
-		provider = "provider-family-azure"
-	default:
+	provider := familyNameForMonolith(extractProviderNameFromPackageName(s.Spec.PackageSpec.Package), pc.Registry)
+
+	version, err := pc.Resolver.Resolve(provider)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot resolve version for %q", provider)
 	}
 
 	p := xppkgv1.Provider{}
 	p.ObjectMeta.Name = provider
 	p.Spec.PackageSpec = xppkgv1.PackageSpec{
-		Package:                  fmt.Sprintf("%s/%s:%s", "xpkg.upbound.io/upbound", provider, pc.FamilyVersion),
+		Package:                  fmt.Sprintf("%s:%s", pc.Registry.PackageURL(provider), version),
 		RevisionActivationPolicy: &ap,
 	}
+	p.Spec.ControllerConfigReference = s.Spec.ControllerConfigReference
+	p.Spec.RuntimeConfigReference = s.Spec.RuntimeConfigReference
 
 	return []xppkgv1.Provider{p}, nil
 }
 
+// RuntimeConfigSplitter lets a migration right-size the fleet instead of
+// every service-scoped provider inheriting the monolith's resource
+// envelope, e.g. smaller memory for provider-aws-iam and larger for
+// provider-aws-ec2.
+type RuntimeConfigSplitter interface {
+	// Split returns the RuntimeConfigReference to use for providerName,
+	// given the monolith's own reference, plus any DeploymentRuntimeConfig
+	// manifests that reference needs added to the migration plan. It is
+	// fine for an implementation to return monolithRef unchanged and no
+	// additional manifests for providers it doesn't want to right-size.
+	Split(providerName string, monolithRef *xppkgv1.RuntimeConfigReference) (*xppkgv1.RuntimeConfigReference, []xppkgv1beta1.DeploymentRuntimeConfig, error)
+}
+
 type ProviderPkgFamilyParameters struct {
-	FamilyVersion        string
+	Registry             *RegistryConfig
+	Resolver             VersionResolver
 	Monolith             string
 	CompositionProcessor *compositionPreProcessor
+	// RuntimeConfigSplitter, if set, is consulted for each service-scoped
+	// provider's RuntimeConfigReference instead of copying the monolith's
+	// reference through unchanged.
+	RuntimeConfigSplitter RuntimeConfigSplitter
+	// AdditionalManifests accumulates the DeploymentRuntimeConfig objects
+	// RuntimeConfigSplitter produces, so they can be added to the migration
+	// plan alongside the new Providers.
+	AdditionalManifests []xppkgv1beta1.DeploymentRuntimeConfig
 }
 
+// ProviderPackageV1 creates the service-scoped Providers with Manual
+// activation. Like ProviderPkgFamilyConfigParameters.ProviderPackageV1, it
+// is part of stepConfigurationPackageDisableDepResolution, so the new
+// providers exist, unactivated, ahead of the Lock edit and monolith
+// deletion. The monolith's ControllerConfigReference and
+// RuntimeConfigReference are carried over to every service-scoped provider,
+// since the monolith's resource limits, node selectors, tolerations,
+// service accounts and image pull secrets are wired through those, and
+// silently dropping them would break the new providers. When
+// RuntimeConfigSplitter is set, the RuntimeConfigReference it returns is
+// used instead of the monolith's.
 func (pf *ProviderPkgFamilyParameters) ProviderPackageV1(p xppkgv1.Provider) ([]xppkgv1.Provider, error) {
 	ap := xppkgv1.ManualActivation
+	if extractProviderNameFromPackageName(p.Spec.PackageSpec.Package) != pf.Monolith {
+		return nil, nil
+	}
+
 	var providers []xppkgv1.Provider
 	for providerName := range pf.CompositionProcessor.ProviderNames {
-		if providerName == "provider-family-aws" || providerName == "provider-family-azure" || providerName == "provider-family-gcp" {
+		if pf.Registry.IsFamilyName(providerName) {
 			continue
 		}
-		if extractProviderNameFromPackageName(p.Spec.PackageSpec.Package) == pf.Monolith {
-			providers = append(providers, xppkgv1.Provider{
-				ObjectMeta: metav1.ObjectMeta{
-					Name: providerName,
-				},
-				Spec: xppkgv1.ProviderSpec{
-					PackageSpec: xppkgv1.PackageSpec{
-						Package:                  fmt.Sprintf("%s/%s:%s", "xpkg.upbound.io/upbound", providerName, pf.FamilyVersion),
-						RevisionActivationPolicy: &ap,
-					},
-				},
-			})
+		version, err := pf.Resolver.Resolve(providerName)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot resolve version for %q", providerName)
+		}
+
+		runtimeConfigRef := p.Spec.RuntimeConfigReference
+		if pf.RuntimeConfigSplitter != nil {
+			ref, additional, err := pf.RuntimeConfigSplitter.Split(providerName, p.Spec.RuntimeConfigReference)
+			if err != nil {
+				return nil, errors.Wrapf(err, "cannot split runtime config for %q", providerName)
+			}
+			runtimeConfigRef = ref
+			pf.AdditionalManifests = append(pf.AdditionalManifests, additional...)
 		}
+
+		providers = append(providers, xppkgv1.Provider{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: providerName,
+			},
+			Spec: xppkgv1.ProviderSpec{
+				PackageSpec: xppkgv1.PackageSpec{
+					Package:                  fmt.Sprintf("%s:%s", pf.Registry.PackageURL(providerName), version),
+					RevisionActivationPolicy: &ap,
+				},
+				ControllerConfigReference: p.Spec.ControllerConfigReference,
+				RuntimeConfigReference:    runtimeConfigRef,
+			},
+		})
 	}
 	return providers, nil
 }
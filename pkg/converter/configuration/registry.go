@@ -0,0 +1,109 @@
+// Copyright 2023 Upbound Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configuration
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MonolithMapping describes how a single monolithic provider maps onto its
+// service-scoped family, so the converters in this package don't have to
+// hardcode a switch over a handful of known clouds.
+type MonolithMapping struct {
+	// MonolithPackage is the monolithic provider's package name, without
+	// registry host, organization or tag, e.g. "provider-aws".
+	MonolithPackage string
+	// FamilyName is the family provider's package name, e.g.
+	// "provider-family-aws".
+	FamilyName string
+	// GroupSuffix is the suffix shared by this provider's managed resource
+	// and composition API groups, e.g. "aws" for "ec2.aws.upbound.io".
+	GroupSuffix string
+}
+
+// RegistryConfig describes the package registry and the monolith-to-family
+// mappings a Configuration migration should use. It replaces the
+// xpkg.upbound.io/upbound and provider-aws/azure/gcp assumptions this
+// package used to hardcode, so migrations such as Equinix, Alibaba,
+// Kubernetes, Terraform and other community providers following the same
+// monolith-to-family split can reuse the same converters, and so users of a
+// private registry or xpkg.crossplane.io don't have to fork the code.
+type RegistryConfig struct {
+	// Host is the registry host, e.g. "xpkg.upbound.io".
+	Host string
+	// Organization is the registry organization packages are published
+	// under, e.g. "upbound".
+	Organization string
+	// Monoliths holds one MonolithMapping per monolithic provider this
+	// migration knows how to split.
+	Monoliths []MonolithMapping
+}
+
+// DefaultRegistryConfig returns the xpkg.upbound.io/upbound registry and the
+// provider-aws/provider-azure/provider-gcp monolith mappings this package
+// originally shipped with.
+func DefaultRegistryConfig() *RegistryConfig {
+	return &RegistryConfig{
+		Host:         "xpkg.upbound.io",
+		Organization: "upbound",
+		Monoliths: []MonolithMapping{
+			{MonolithPackage: "provider-aws", FamilyName: "provider-family-aws", GroupSuffix: "aws"},
+			{MonolithPackage: "provider-azure", FamilyName: "provider-family-azure", GroupSuffix: "azure"},
+			{MonolithPackage: "provider-gcp", FamilyName: "provider-family-gcp", GroupSuffix: "gcp"},
+		},
+	}
+}
+
+// PackageURL returns the fully-qualified package reference for name, e.g.
+// "xpkg.upbound.io/upbound/provider-aws-s3".
+func (r *RegistryConfig) PackageURL(name string) string {
+	return fmt.Sprintf("%s/%s/%s", r.Host, r.Organization, name)
+}
+
+// MonolithByPackage returns the MonolithMapping whose MonolithPackage
+// matches name, and whether one was found.
+func (r *RegistryConfig) MonolithByPackage(name string) (MonolithMapping, bool) {
+	for _, m := range r.Monoliths {
+		if m.MonolithPackage == name {
+			return m, true
+		}
+	}
+	return MonolithMapping{}, false
+}
+
+// MonolithByGroupSuffix returns the MonolithMapping whose GroupSuffix
+// matches suffix, and whether one was found.
+func (r *RegistryConfig) MonolithByGroupSuffix(suffix string) (MonolithMapping, bool) {
+	for _, m := range r.Monoliths {
+		if m.GroupSuffix == suffix {
+			return m, true
+		}
+	}
+	return MonolithMapping{}, false
+}
+
+// IsFamilyName reports whether name is the FamilyName of any configured
+// MonolithMapping, or follows the "provider-family-<suffix>" convention that
+// familyName/familyNameForMonolith fall back to for monoliths that aren't
+// explicitly registered in Monoliths.
+func (r *RegistryConfig) IsFamilyName(name string) bool {
+	for _, m := range r.Monoliths {
+		if m.FamilyName == name {
+			return true
+		}
+	}
+	return strings.HasPrefix(name, "provider-family-")
+}
@@ -0,0 +1,65 @@
+// Copyright 2023 Upbound Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configuration
+
+import "testing"
+
+func TestFamilyNameForMonolithUnmappedAgreesWithIsFamilyName(t *testing.T) {
+	registry := DefaultRegistryConfig()
+
+	cases := map[string]struct {
+		monolithPackage string
+		want            string
+	}{
+		"RegisteredMonolith": {
+			monolithPackage: "provider-aws",
+			want:            "provider-family-aws",
+		},
+		"UnregisteredMonolith": {
+			monolithPackage: "provider-equinix",
+			want:            "provider-family-equinix",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := familyNameForMonolith(tc.monolithPackage, registry)
+			if got != tc.want {
+				t.Fatalf("familyNameForMonolith(%q) = %q, want %q", tc.monolithPackage, got, tc.want)
+			}
+			if got == tc.monolithPackage {
+				t.Fatalf("familyNameForMonolith(%q) returned the monolith's own name, which would collide with its still-live Provider object", tc.monolithPackage)
+			}
+			if !registry.IsFamilyName(got) {
+				t.Fatalf("IsFamilyName(%q) = false, want true: the two must agree on every synthesized family name", got)
+			}
+		})
+	}
+}
+
+func TestFamilyNameAgreesWithFamilyNameForMonolith(t *testing.T) {
+	registry := DefaultRegistryConfig()
+
+	// familyName is keyed by group suffix (e.g. "aws" from
+	// "ec2.aws.upbound.io"), familyNameForMonolith by the monolith's package
+	// name (e.g. "provider-aws"); for an unmapped monolith whose package
+	// name follows the "provider-<suffix>" convention, both must fall back
+	// to the same synthesized name.
+	got := familyNameForMonolith("provider-equinix", registry)
+	want := familyName("equinix", registry)
+	if got != want {
+		t.Fatalf("familyNameForMonolith(%q) = %q, familyName(%q) = %q; fallbacks disagree", "provider-equinix", got, "equinix", want)
+	}
+}
@@ -0,0 +1,70 @@
+// Copyright 2023 Upbound Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configuration
+
+// Step identifies an ordered phase of a Configuration package migration.
+// Modeled on upjet's configurationpackage_steps, the steps exist because the
+// package manager will happily re-resolve and reinstall the monolithic
+// provider if dependency resolution is left enabled while the migration is
+// in flight, so every phase that touches the Lock or the monolith must run
+// with dependency resolution disabled and in the order below.
+type Step string
+
+const (
+	// stepConfigurationPackageDisableDepResolution patches the current
+	// Configuration package's spec.skipDependencyResolution to true and its
+	// spec.revisionActivationPolicy to Manual, and creates the new
+	// service-scoped Provider packages with Manual activation, so neither
+	// the monolith nor the new providers are resolved or activated behind
+	// our back while the migration runs.
+	stepConfigurationPackageDisableDepResolution Step = "configuration-package-disable-dependency-resolution"
+	// stepPatchSkipDependencyResolution is the underlying patch applied to
+	// a Configuration package's spec.skipDependencyResolution field. It is
+	// shared by stepConfigurationPackageDisableDepResolution (value true)
+	// and stepConfigurationPackageEnableDepResolution (value false).
+	stepPatchSkipDependencyResolution Step = "patch-skip-dependency-resolution"
+	// stepDeleteMonolithicProvider edits the package Lock to remove the
+	// monolithic provider's entries, then deletes the monolithic Provider
+	// itself, so the deleted monolith can't be re-resolved back onto the
+	// cluster.
+	stepDeleteMonolithicProvider Step = "delete-monolithic-provider"
+	// stepActivateServiceScopedProviderRevision activates the
+	// ProviderRevisions of the newly created service-scoped Providers.
+	stepActivateServiceScopedProviderRevision Step = "activate-service-scoped-provider-revision"
+	// stepEditConfigurationMetadata rewrites the Configuration's meta
+	// dependsOn list to depend on the service-scoped providers instead of
+	// the monolith, ready to be repackaged and pushed.
+	stepEditConfigurationMetadata Step = "edit-configuration-metadata"
+	// stepEditConfigurationPackage points the on-cluster Configuration.pkg
+	// spec.package at the newly pushed package URL.
+	stepEditConfigurationPackage Step = "edit-configuration-package"
+	// stepConfigurationPackageEnableDepResolution flips
+	// spec.skipDependencyResolution back to false now that the monolith
+	// can no longer be resolved.
+	stepConfigurationPackageEnableDepResolution Step = "configuration-package-enable-dependency-resolution"
+)
+
+// MigrationSteps returns the ordered sequence of steps a Configuration
+// package migration must be applied in. See Step for why the order matters.
+func MigrationSteps() []Step {
+	return []Step{
+		stepConfigurationPackageDisableDepResolution,
+		stepDeleteMonolithicProvider,
+		stepActivateServiceScopedProviderRevision,
+		stepEditConfigurationMetadata,
+		stepEditConfigurationPackage,
+		stepConfigurationPackageEnableDepResolution,
+	}
+}
@@ -0,0 +1,195 @@
+// Copyright 2023 Upbound Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configuration
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/pkg/errors"
+)
+
+// VersionResolver resolves the concrete version to stamp on a provider
+// dependency or a newly created Provider package. Real fleets pin different
+// services to different releases, e.g. provider-aws-s3 at v1.2.0 while
+// provider-aws-eks is still on v1.0.3, so a single FamilyVersion string for
+// every provider is not enough.
+type VersionResolver interface {
+	// Resolve returns the version to use for providerName, e.g.
+	// "provider-aws-s3".
+	Resolve(providerName string) (string, error)
+}
+
+// TagLister lists the available tags for a package in a registry. It exists
+// so RegistryVersionResolver's registry queries can be substituted with a
+// fake in tests.
+type TagLister interface {
+	ListTags(packageURL string) ([]string, error)
+}
+
+// RegistryVersionResolver resolves each provider's version by querying a
+// registry for its available tags and picking the highest one satisfying
+// Constraint, unless Overrides pins it to a specific version.
+type RegistryVersionResolver struct {
+	Registry *RegistryConfig
+	Lister   TagLister
+	// Constraint is a semver constraint, e.g. ">=1.2.0", applied to every
+	// provider that isn't pinned by Overrides.
+	Constraint string
+	// Overrides pins specific providers, keyed by provider name, e.g.
+	// "provider-aws-eks", to an exact version.
+	Overrides map[string]string
+}
+
+// NewRegistryVersionResolver returns a RegistryVersionResolver for the given
+// registry, tag lister, constraint and per-provider overrides.
+func NewRegistryVersionResolver(registry *RegistryConfig, lister TagLister, constraint string, overrides map[string]string) *RegistryVersionResolver {
+	return &RegistryVersionResolver{
+		Registry:   registry,
+		Lister:     lister,
+		Constraint: constraint,
+		Overrides:  overrides,
+	}
+}
+
+// NewDefaultVersionResolver returns the VersionResolver a migration gets
+// out of the box: an OCITagLister querying registry's own host for
+// available tags, memoized so that resolving the same provider name twice
+// in one migration run (e.g. once for a Dependency and again for the
+// Provider package that backs it) always returns the same version instead
+// of risking two independent registry round-trips disagreeing.
+func NewDefaultVersionResolver(registry *RegistryConfig, constraint string, overrides map[string]string) VersionResolver {
+	return NewMemoizingVersionResolver(NewRegistryVersionResolver(registry, NewOCITagLister(), constraint, overrides))
+}
+
+// Resolve implements VersionResolver.
+func (r *RegistryVersionResolver) Resolve(providerName string) (string, error) {
+	if v, ok := r.Overrides[providerName]; ok {
+		return v, nil
+	}
+
+	constraint, err := semver.NewConstraint(r.Constraint)
+	if err != nil {
+		return "", errors.Wrapf(err, "invalid version constraint %q", r.Constraint)
+	}
+
+	tags, err := r.Lister.ListTags(r.Registry.PackageURL(providerName))
+	if err != nil {
+		return "", errors.Wrapf(err, "cannot list tags for %q", providerName)
+	}
+
+	var versions []*semver.Version
+	for _, tag := range tags {
+		v, err := semver.NewVersion(tag)
+		if err != nil {
+			// Not every tag is necessarily a semantic version, e.g. a
+			// "latest" or "nightly" moving tag. Skip those instead of
+			// failing the whole resolution.
+			continue
+		}
+		if constraint.Check(v) {
+			versions = append(versions, v)
+		}
+	}
+	if len(versions) == 0 {
+		return "", errors.Errorf("no tag for %q satisfies constraint %q", providerName, r.Constraint)
+	}
+
+	sort.Sort(semver.Collection(versions))
+	return versions[len(versions)-1].Original(), nil
+}
+
+// OCITagLister lists tags by querying a registry's OCI Distribution
+// "tags/list" endpoint, e.g. https://xpkg.upbound.io/v2/upbound/provider-aws-s3/tags/list.
+// This is the default TagLister, since xpkg.upbound.io and most other
+// package registries, including private ones, speak the OCI Distribution
+// API.
+type OCITagLister struct {
+	Client *http.Client
+}
+
+// NewOCITagLister returns an OCITagLister using http.DefaultClient.
+func NewOCITagLister() *OCITagLister {
+	return &OCITagLister{Client: http.DefaultClient}
+}
+
+// ListTags implements TagLister.
+func (l *OCITagLister) ListTags(packageURL string) ([]string, error) {
+	host, name, ok := strings.Cut(packageURL, "/")
+	if !ok {
+		return nil, errors.Errorf("invalid package URL %q", packageURL)
+	}
+
+	resp, err := l.Client.Get(fmt.Sprintf("https://%s/v2/%s/tags/list", host, name))
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot reach registry for %q", packageURL)
+	}
+	defer resp.Body.Close() //nolint:errcheck // Best effort, we already have the response body or an error.
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("registry returned status %q listing tags for %q", resp.Status, packageURL)
+	}
+
+	var body struct {
+		Tags []string `json:"tags"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, errors.Wrapf(err, "cannot decode tags/list response for %q", packageURL)
+	}
+	return body.Tags, nil
+}
+
+// MemoizingVersionResolver wraps another VersionResolver and caches its
+// result per provider name, so every caller within a migration run that
+// resolves the same provider gets the exact same version back instead of
+// each triggering its own registry round-trip that could, in principle,
+// observe a different tag.
+type MemoizingVersionResolver struct {
+	Resolver VersionResolver
+
+	mu    sync.Mutex
+	cache map[string]string
+}
+
+// NewMemoizingVersionResolver returns a MemoizingVersionResolver wrapping
+// resolver.
+func NewMemoizingVersionResolver(resolver VersionResolver) *MemoizingVersionResolver {
+	return &MemoizingVersionResolver{
+		Resolver: resolver,
+		cache:    map[string]string{},
+	}
+}
+
+// Resolve implements VersionResolver.
+func (m *MemoizingVersionResolver) Resolve(providerName string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if v, ok := m.cache[providerName]; ok {
+		return v, nil
+	}
+
+	v, err := m.Resolver.Resolve(providerName)
+	if err != nil {
+		return "", err
+	}
+	m.cache[providerName] = v
+	return v, nil
+}
@@ -0,0 +1,118 @@
+// Copyright 2023 Upbound Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configuration
+
+import (
+	"testing"
+)
+
+type fakeTagLister struct {
+	tags map[string][]string
+	err  error
+	// calls counts ListTags invocations per packageURL, so tests can assert
+	// MemoizingVersionResolver only hits the lister once per provider.
+	calls map[string]int
+}
+
+func (f *fakeTagLister) ListTags(packageURL string) ([]string, error) {
+	if f.calls == nil {
+		f.calls = map[string]int{}
+	}
+	f.calls[packageURL]++
+	if f.err != nil {
+		return nil, f.err
+	}
+	return f.tags[packageURL], nil
+}
+
+func TestRegistryVersionResolverResolve(t *testing.T) {
+	registry := DefaultRegistryConfig()
+
+	cases := map[string]struct {
+		provider   string
+		tags       []string
+		constraint string
+		overrides  map[string]string
+		want       string
+		wantErr    bool
+	}{
+		"PicksHighestSatisfyingTag": {
+			provider:   "provider-aws-s3",
+			tags:       []string{"v1.0.0", "v1.2.0", "v1.1.0", "not-a-version"},
+			constraint: ">=1.0.0",
+			want:       "v1.2.0",
+		},
+		"HonorsOverride": {
+			provider:   "provider-aws-eks",
+			tags:       []string{"v2.0.0"},
+			constraint: ">=1.0.0",
+			overrides:  map[string]string{"provider-aws-eks": "v1.0.3"},
+			want:       "v1.0.3",
+		},
+		"NoTagSatisfiesConstraint": {
+			provider:   "provider-aws-iam",
+			tags:       []string{"v0.9.0"},
+			constraint: ">=1.0.0",
+			wantErr:    true,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			lister := &fakeTagLister{tags: map[string][]string{
+				registry.PackageURL(tc.provider): tc.tags,
+			}}
+			r := NewRegistryVersionResolver(registry, lister, tc.constraint, tc.overrides)
+
+			got, err := r.Resolve(tc.provider)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("Resolve(%q): expected error, got version %q", tc.provider, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Resolve(%q): unexpected error: %v", tc.provider, err)
+			}
+			if got != tc.want {
+				t.Fatalf("Resolve(%q) = %q, want %q", tc.provider, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestMemoizingVersionResolverResolvesOnce(t *testing.T) {
+	registry := DefaultRegistryConfig()
+	lister := &fakeTagLister{tags: map[string][]string{
+		registry.PackageURL("provider-aws-s3"): {"v1.2.0"},
+	}}
+	resolver := NewMemoizingVersionResolver(NewRegistryVersionResolver(registry, lister, ">=1.0.0", nil))
+
+	first, err := resolver.Resolve("provider-aws-s3")
+	if err != nil {
+		t.Fatalf("first Resolve: unexpected error: %v", err)
+	}
+	second, err := resolver.Resolve("provider-aws-s3")
+	if err != nil {
+		t.Fatalf("second Resolve: unexpected error: %v", err)
+	}
+
+	if first != second {
+		t.Fatalf("memoized resolutions disagree: %q != %q", first, second)
+	}
+	if calls := lister.calls[registry.PackageURL("provider-aws-s3")]; calls != 1 {
+		t.Fatalf("ListTags called %d times, want 1", calls)
+	}
+}
@@ -0,0 +1,84 @@
+// Copyright 2023 Upbound Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package executor
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/sergenyalcin/extensions-migration/pkg/converter/configuration"
+)
+
+// Executor applies a Plan's StepPlans against a live cluster, in order,
+// so that a migration can't be run out of sequence by hand.
+type Executor interface {
+	// Init prepares the executor to run, e.g. verifying kubectl is
+	// available and the target cluster is reachable.
+	Init(ctx context.Context) error
+	// Step applies a single StepPlan and returns a short, human-readable
+	// description of what it did.
+	Step(ctx context.Context, step StepPlan) (string, error)
+	// Destroy releases any resources the executor acquired in Init.
+	Destroy(ctx context.Context) error
+}
+
+// ValidateStepOrder checks that p's Steps appear in the same relative order
+// as configuration.MigrationSteps(), so that a hand-authored or hand-edited
+// Plan can't be run out of sequence, whatever order its YAML happens to
+// list its steps in.
+func ValidateStepOrder(p Plan) error {
+	order := configuration.MigrationSteps()
+	index := make(map[configuration.Step]int, len(order))
+	for i, s := range order {
+		index[s] = i
+	}
+
+	last := -1
+	for _, sp := range p.Steps {
+		i, ok := index[sp.Step]
+		if !ok {
+			return errors.Errorf("unknown migration step %q", sp.Step)
+		}
+		if i <= last {
+			return errors.Errorf("step %q is out of order", sp.Step)
+		}
+		last = i
+	}
+	return nil
+}
+
+// Run applies every StepPlan in p against e, in order, stopping at the first
+// error. It refuses to run a Plan whose Steps aren't in
+// configuration.MigrationSteps() order.
+func Run(ctx context.Context, e Executor, p Plan) ([]string, error) {
+	if err := ValidateStepOrder(p); err != nil {
+		return nil, errors.Wrap(err, "refusing to run plan")
+	}
+	if err := e.Init(ctx); err != nil {
+		return nil, err
+	}
+	defer func() { _ = e.Destroy(ctx) }()
+
+	results := make([]string, 0, len(p.Steps))
+	for _, sp := range p.Steps {
+		result, err := e.Step(ctx, sp)
+		if err != nil {
+			return results, err
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
@@ -0,0 +1,265 @@
+// Copyright 2023 Upbound Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package executor
+
+import (
+	"encoding/json"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	xppkgv1 "github.com/crossplane/crossplane/apis/pkg/v1"
+	xppkgv1beta1 "github.com/crossplane/crossplane/apis/pkg/v1beta1"
+	"github.com/pkg/errors"
+
+	"github.com/sergenyalcin/extensions-migration/pkg/converter/configuration"
+)
+
+const (
+	errGenDisableDepResolution = "cannot disable dependency resolution"
+	errGenCreateProviders      = "cannot build service-scoped Provider packages"
+	errGenEditLock             = "cannot edit package Lock"
+	errGenEditPackage          = "cannot edit Configuration package"
+	errGenEnableDepResolution  = "cannot re-enable dependency resolution"
+	errGenMarshalManifest      = "cannot marshal generated manifest"
+	errGenMarshalPatch         = "cannot marshal generated patch"
+)
+
+// GenerateInput bundles the live cluster objects and configured converters a
+// single Configuration package migration needs to build a Plan from. Family
+// and FamilyConfig should share their configuration.RegistryConfig and
+// configuration.VersionResolver with each other, and with the
+// ConfigMetaParameters used elsewhere to rewrite the Configuration's
+// package sources, so a provider's Dependency and its Provider package
+// always agree on the resolved version. Use a
+// configuration.MemoizingVersionResolver to enforce this.
+type GenerateInput struct {
+	// Configuration is the on-cluster Configuration.pkg object being
+	// migrated.
+	Configuration *xppkgv1.Configuration
+	// Lock is the on-cluster package Lock.
+	Lock *xppkgv1beta1.Lock
+	// Monolith is the on-cluster monolithic Provider being replaced.
+	Monolith xppkgv1.Provider
+
+	ConfigPkg        *configuration.ConfigPkgParameters
+	LockPkg          *configuration.LockParameters
+	Family           *configuration.ProviderPkgFamilyParameters
+	FamilyConfig     *configuration.ProviderPkgFamilyConfigParameters
+	RevisionActivate *configuration.ProviderRevisionActivationParameters
+}
+
+// GeneratePlan runs in's converters against in's live cluster objects and
+// assembles the results into a Plan whose Steps follow
+// configuration.MigrationSteps() order, so the plan this package executes
+// is always derived from the same converters the rest of this tool uses to
+// rewrite the Configuration's package sources, instead of being
+// hand-authored separately.
+func GeneratePlan(in GenerateInput) (Plan, error) {
+	steps := configuration.MigrationSteps()
+	if len(steps) != 6 {
+		return Plan{}, errors.Errorf("unexpected number of migration steps: %d", len(steps))
+	}
+
+	familyProviders, err := in.FamilyConfig.ProviderPackageV1(in.Monolith)
+	if err != nil {
+		return Plan{}, errors.Wrap(err, errGenCreateProviders)
+	}
+	serviceProviders, err := in.Family.ProviderPackageV1(in.Monolith)
+	if err != nil {
+		return Plan{}, errors.Wrap(err, errGenCreateProviders)
+	}
+	newProviders := append(familyProviders, serviceProviders...)
+
+	disableStep, afterDisable, err := disableDepResolutionStep(steps[0], in.ConfigPkg, in.Configuration, newProviders, in.Family.AdditionalManifests)
+	if err != nil {
+		return Plan{}, err
+	}
+
+	deleteStep, err := deleteMonolithStep(steps[1], in.LockPkg, in.Lock, in.Monolith)
+	if err != nil {
+		return Plan{}, err
+	}
+
+	activateStep := activateRevisionsStep(steps[2], in.RevisionActivate, newProviders)
+
+	// stepEditConfigurationMetadata rewrites the Configuration's package
+	// sources (crossplane.yaml) ahead of repackaging and pushing a new
+	// Configuration image; it has no live-cluster object to apply, so it
+	// carries no kubectl work here and exists in the Plan purely to keep
+	// its place in the sequence ValidateStepOrder checks.
+	metadataStep := StepPlan{Step: steps[3]}
+
+	packageStep, afterPackage, err := editPackageStep(steps[4], in.ConfigPkg, afterDisable)
+	if err != nil {
+		return Plan{}, err
+	}
+
+	enableStep, err := enableDepResolutionStep(steps[5], in.ConfigPkg, afterPackage)
+	if err != nil {
+		return Plan{}, err
+	}
+
+	plan := Plan{Steps: []StepPlan{disableStep, deleteStep, activateStep, metadataStep, packageStep, enableStep}}
+	return plan, ValidateStepOrder(plan)
+}
+
+// disableDepResolutionStep builds the StepPlan for steps[0] and also returns
+// the patched Configuration it produced, so later steps that further mutate
+// the Configuration (editPackageStep, enableDepResolutionStep) build their
+// patches on top of this step's changes instead of the stale pre-migration
+// object. additionalManifests are the DeploymentRuntimeConfig objects
+// ProviderPkgFamilyParameters.RuntimeConfigSplitter produced for the new
+// providers; they're applied alongside those providers since the providers'
+// RuntimeConfigReference points at them.
+func disableDepResolutionStep(step configuration.Step, cp *configuration.ConfigPkgParameters, current *xppkgv1.Configuration, newProviders []xppkgv1.Provider, additionalManifests []xppkgv1beta1.DeploymentRuntimeConfig) (StepPlan, *xppkgv1.Configuration, error) {
+	patched := current.DeepCopy()
+	if err := cp.DisableDependencyResolution(patched); err != nil {
+		return StepPlan{}, nil, errors.Wrap(err, errGenDisableDepResolution)
+	}
+	patch, err := specMergePatch(patched.Spec)
+	if err != nil {
+		return StepPlan{}, nil, errors.Wrap(err, errGenDisableDepResolution)
+	}
+
+	apply := make([]unstructured.Unstructured, 0, len(newProviders)+len(additionalManifests))
+	for i := range newProviders {
+		u, err := providerToUnstructured(&newProviders[i])
+		if err != nil {
+			return StepPlan{}, nil, errors.Wrap(err, errGenCreateProviders)
+		}
+		apply = append(apply, u)
+	}
+	for i := range additionalManifests {
+		u, err := toUnstructured(&additionalManifests[i], "pkg.crossplane.io/v1beta1", "DeploymentRuntimeConfig")
+		if err != nil {
+			return StepPlan{}, nil, errors.Wrap(err, errGenCreateProviders)
+		}
+		apply = append(apply, u)
+	}
+
+	return StepPlan{
+		Step:    step,
+		Patches: []Patch{{Ref: objectRef(current, "pkg.crossplane.io/v1", "Configuration"), Patch: patch}},
+		Apply:   apply,
+	}, patched, nil
+}
+
+func deleteMonolithStep(step configuration.Step, l *configuration.LockParameters, current *xppkgv1beta1.Lock, monolith xppkgv1.Provider) (StepPlan, error) {
+	patched := current.DeepCopy()
+	if err := l.PackageLockV1Beta1(patched); err != nil {
+		return StepPlan{}, errors.Wrap(err, errGenEditLock)
+	}
+	patch, err := json.Marshal(struct {
+		Packages []xppkgv1beta1.LockPackage `json:"packages"`
+	}{Packages: patched.Packages})
+	if err != nil {
+		return StepPlan{}, errors.Wrap(err, errGenMarshalPatch)
+	}
+
+	return StepPlan{
+		Step:    step,
+		Patches: []Patch{{Ref: objectRef(current, "pkg.crossplane.io/v1beta1", "Lock"), Patch: string(patch)}},
+		Delete:  []ObjectRef{objectRef(&monolith, "pkg.crossplane.io/v1", "Provider")},
+	}, nil
+}
+
+func activateRevisionsStep(step configuration.Step, ra *configuration.ProviderRevisionActivationParameters, newProviders []xppkgv1.Provider) StepPlan {
+	names := make(map[string]struct{}, len(newProviders))
+	for _, p := range newProviders {
+		names[p.Name] = struct{}{}
+	}
+	ra.ServiceScopedProviders = names
+
+	waitFor := make([]ObjectRef, 0, len(newProviders))
+	for _, p := range newProviders {
+		waitFor = append(waitFor, ObjectRef{APIVersion: "pkg.crossplane.io/v1", Kind: "ProviderRevision", Name: p.Name})
+	}
+
+	return StepPlan{Step: step, WaitFor: waitFor}
+}
+
+// editPackageStep builds the StepPlan for steps[4] and also returns the
+// patched Configuration it produced, so enableDepResolutionStep's patch is
+// built on top of the new package URL instead of the pre-migration one.
+func editPackageStep(step configuration.Step, cp *configuration.ConfigPkgParameters, current *xppkgv1.Configuration) (StepPlan, *xppkgv1.Configuration, error) {
+	patched := current.DeepCopy()
+	if err := cp.ConfigurationPackageV1(patched); err != nil {
+		return StepPlan{}, nil, errors.Wrap(err, errGenEditPackage)
+	}
+	u, err := toUnstructured(patched, "pkg.crossplane.io/v1", "Configuration")
+	if err != nil {
+		return StepPlan{}, nil, errors.Wrap(err, errGenMarshalManifest)
+	}
+	return StepPlan{Step: step, Apply: []unstructured.Unstructured{u}}, patched, nil
+}
+
+func enableDepResolutionStep(step configuration.Step, cp *configuration.ConfigPkgParameters, current *xppkgv1.Configuration) (StepPlan, error) {
+	patched := current.DeepCopy()
+	if err := cp.EnableDependencyResolution(patched); err != nil {
+		return StepPlan{}, errors.Wrap(err, errGenEnableDepResolution)
+	}
+	patch, err := specMergePatch(patched.Spec)
+	if err != nil {
+		return StepPlan{}, errors.Wrap(err, errGenEnableDepResolution)
+	}
+	return StepPlan{
+		Step:    step,
+		Patches: []Patch{{Ref: objectRef(current, "pkg.crossplane.io/v1", "Configuration"), Patch: patch}},
+	}, nil
+}
+
+// specMergePatch returns the JSON merge patch body for spec in full, e.g.
+// {"spec":{"skipDependencyResolution":true,"package":"..."}}. A JSON merge
+// patch replaces every field it doesn't omit, so spec must already reflect
+// every earlier step's mutations and not just the ones the current step
+// made — see how GeneratePlan threads the patched Configuration from step to
+// step. Patching from a stale copy would silently revert whatever an
+// earlier step changed.
+func specMergePatch(spec interface{}) (string, error) {
+	body, err := json.Marshal(struct {
+		Spec interface{} `json:"spec"`
+	}{Spec: spec})
+	if err != nil {
+		return "", errors.Wrap(err, errGenMarshalPatch)
+	}
+	return string(body), nil
+}
+
+func providerToUnstructured(p *xppkgv1.Provider) (unstructured.Unstructured, error) {
+	return toUnstructured(p, "pkg.crossplane.io/v1", "Provider")
+}
+
+func toUnstructured(obj runtime.Object, apiVersion, kind string) (unstructured.Unstructured, error) {
+	m, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return unstructured.Unstructured{}, err
+	}
+	u := unstructured.Unstructured{Object: m}
+	u.SetAPIVersion(apiVersion)
+	u.SetKind(kind)
+	return u, nil
+}
+
+// objectRef builds an ObjectRef for obj, which must embed metav1.ObjectMeta.
+func objectRef(obj metav1.Object, apiVersion, kind string) ObjectRef {
+	return ObjectRef{
+		APIVersion: apiVersion,
+		Kind:       kind,
+		Name:       obj.GetName(),
+		Namespace:  obj.GetNamespace(),
+	}
+}
@@ -0,0 +1,129 @@
+// Copyright 2023 Upbound Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package executor
+
+import (
+	"strings"
+	"testing"
+
+	xppkgv1 "github.com/crossplane/crossplane/apis/pkg/v1"
+	xppkgv1beta1 "github.com/crossplane/crossplane/apis/pkg/v1beta1"
+
+	"github.com/sergenyalcin/extensions-migration/pkg/converter/configuration"
+)
+
+func TestValidateStepOrder(t *testing.T) {
+	steps := configuration.MigrationSteps()
+
+	cases := map[string]struct {
+		plan    Plan
+		wantErr bool
+	}{
+		"InOrder": {
+			plan: Plan{Steps: []StepPlan{{Step: steps[0]}, {Step: steps[1]}, {Step: steps[2]}}},
+		},
+		"OutOfOrder": {
+			plan:    Plan{Steps: []StepPlan{{Step: steps[1]}, {Step: steps[0]}}},
+			wantErr: true,
+		},
+		"DuplicateStep": {
+			plan:    Plan{Steps: []StepPlan{{Step: steps[0]}, {Step: steps[0]}}},
+			wantErr: true,
+		},
+		"UnknownStep": {
+			plan:    Plan{Steps: []StepPlan{{Step: configuration.Step("bogus")}}},
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := ValidateStepOrder(tc.plan)
+			if tc.wantErr && err == nil {
+				t.Fatal("ValidateStepOrder(): expected an error, got nil")
+			}
+			if !tc.wantErr && err != nil {
+				t.Fatalf("ValidateStepOrder(): unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+type fakeResolver struct {
+	version string
+}
+
+func (f *fakeResolver) Resolve(string) (string, error) {
+	return f.version, nil
+}
+
+// TestGeneratePlanDoesNotRevertPackageURL is a regression test: the enable
+// step used to be built from the pre-migration Configuration, so its JSON
+// merge patch re-emitted the stale spec.package alongside the new
+// skipDependencyResolution value, silently reverting editPackageStep's
+// change once applied.
+func TestGeneratePlanDoesNotRevertPackageURL(t *testing.T) {
+	registry := configuration.DefaultRegistryConfig()
+	resolver := &fakeResolver{version: "v1.0.0"}
+
+	monolith := xppkgv1.Provider{}
+	monolith.Spec.PackageSpec.Package = registry.PackageURL("provider-aws") + ":v9.9.9"
+
+	cfg := &xppkgv1.Configuration{}
+	cfg.Spec.Package = registry.PackageURL("old-config") + ":v1.0.0"
+
+	in := GenerateInput{
+		Configuration: cfg,
+		Lock:          &xppkgv1beta1.Lock{},
+		Monolith:      monolith,
+		ConfigPkg:     &configuration.ConfigPkgParameters{PackageURL: registry.PackageURL("new-config") + ":v2.0.0"},
+		LockPkg:       &configuration.LockParameters{Registry: registry},
+		Family: &configuration.ProviderPkgFamilyParameters{
+			Registry:             registry,
+			Resolver:             resolver,
+			Monolith:             "provider-aws",
+			CompositionProcessor: configuration.NewCompositionPreProcessor(registry),
+		},
+		FamilyConfig: &configuration.ProviderPkgFamilyConfigParameters{
+			Registry: registry,
+			Resolver: resolver,
+		},
+		RevisionActivate: &configuration.ProviderRevisionActivationParameters{},
+	}
+
+	plan, err := GeneratePlan(in)
+	if err != nil {
+		t.Fatalf("GeneratePlan: unexpected error: %v", err)
+	}
+
+	var lastConfigurationPatch string
+	for _, sp := range plan.Steps {
+		for _, p := range sp.Patches {
+			if p.Ref.Kind == "Configuration" {
+				lastConfigurationPatch = p.Patch
+			}
+		}
+	}
+
+	if lastConfigurationPatch == "" {
+		t.Fatal("no Configuration patch found in the generated plan")
+	}
+	if !strings.Contains(lastConfigurationPatch, in.ConfigPkg.PackageURL) {
+		t.Fatalf("final Configuration patch %q does not carry the new package URL %q", lastConfigurationPatch, in.ConfigPkg.PackageURL)
+	}
+	if strings.Contains(lastConfigurationPatch, cfg.Spec.Package) {
+		t.Fatalf("final Configuration patch %q still carries the stale package URL %q", lastConfigurationPatch, cfg.Spec.Package)
+	}
+}
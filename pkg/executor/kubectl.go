@@ -0,0 +1,147 @@
+// Copyright 2023 Upbound Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package executor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	errApply  = "failed to apply manifest"
+	errPatch  = "failed to patch object"
+	errDelete = "failed to delete object"
+	errWait   = "failed waiting for object to become healthy"
+)
+
+// KubectlExecutor is an Executor that shells out to kubectl, so it works
+// against whatever cluster the user's current kubeconfig context points at
+// without this package needing a client-go dependency of its own.
+type KubectlExecutor struct {
+	// Kubeconfig is passed to kubectl via --kubeconfig when non-empty,
+	// otherwise kubectl falls back to its own default resolution.
+	Kubeconfig string
+	// Context is passed to kubectl via --context when non-empty.
+	Context string
+	// WaitTimeout bounds how long Step waits for a StepPlan's WaitFor
+	// objects to become healthy and installed. Defaults to 5 minutes.
+	WaitTimeout time.Duration
+}
+
+// NewKubectlExecutor returns a KubectlExecutor for the given kubeconfig and
+// context, using the default WaitTimeout.
+func NewKubectlExecutor(kubeconfig, kubeContext string) *KubectlExecutor {
+	return &KubectlExecutor{
+		Kubeconfig:  kubeconfig,
+		Context:     kubeContext,
+		WaitTimeout: 5 * time.Minute,
+	}
+}
+
+// Init verifies kubectl is on PATH and can reach the target cluster.
+func (e *KubectlExecutor) Init(ctx context.Context) error {
+	_, err := e.run(ctx, nil, "version")
+	return err
+}
+
+// Destroy is a no-op: KubectlExecutor holds no resources beyond the kubectl
+// subprocesses it starts and waits for on every call.
+func (e *KubectlExecutor) Destroy(_ context.Context) error {
+	return nil
+}
+
+// Step applies sp's patches, manifests, waits and deletions, in that order,
+// so that an object is never deleted before the step that depends on its
+// removal (e.g. the Lock edit) has been applied.
+func (e *KubectlExecutor) Step(ctx context.Context, sp StepPlan) (string, error) {
+	for _, p := range sp.Patches {
+		if err := e.patch(ctx, p); err != nil {
+			return "", errors.Wrap(err, errPatch)
+		}
+	}
+	for _, obj := range sp.Apply {
+		data, err := json.Marshal(obj.Object)
+		if err != nil {
+			return "", errors.Wrap(err, errApply)
+		}
+		if _, err := e.run(ctx, data, "apply", "-f", "-"); err != nil {
+			return "", errors.Wrap(err, errApply)
+		}
+	}
+	for _, ref := range sp.WaitFor {
+		if err := e.wait(ctx, ref); err != nil {
+			return "", errors.Wrap(err, errWait)
+		}
+	}
+	for _, ref := range sp.Delete {
+		if _, err := e.run(ctx, nil, e.refArgs(ref, "delete", "--ignore-not-found")...); err != nil {
+			return "", errors.Wrap(err, errDelete)
+		}
+	}
+	return fmt.Sprintf("applied step %q", sp.Step), nil
+}
+
+func (e *KubectlExecutor) patch(ctx context.Context, p Patch) error {
+	_, err := e.run(ctx, nil, e.refArgs(p.Ref, "patch", "--type=merge", "-p", p.Patch)...)
+	return err
+}
+
+func (e *KubectlExecutor) wait(ctx context.Context, ref ObjectRef) error {
+	timeout := e.WaitTimeout
+	if timeout == 0 {
+		timeout = 5 * time.Minute
+	}
+	waitCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	for _, condition := range []string{"condition=Healthy=True", "condition=Installed=True"} {
+		if _, err := e.run(waitCtx, nil, e.refArgs(ref, "wait", fmt.Sprintf("--for=%s", condition), fmt.Sprintf("--timeout=%s", timeout))...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// refArgs builds "<verb> <kind> <name> [-n namespace] [extra...]".
+func (e *KubectlExecutor) refArgs(ref ObjectRef, verb string, extra ...string) []string {
+	args := []string{verb, ref.Kind, ref.Name}
+	if ref.Namespace != "" {
+		args = append(args, "-n", ref.Namespace)
+	}
+	return append(args, extra...)
+}
+
+func (e *KubectlExecutor) run(ctx context.Context, stdin []byte, args ...string) (string, error) {
+	if e.Kubeconfig != "" {
+		args = append([]string{"--kubeconfig", e.Kubeconfig}, args...)
+	}
+	if e.Context != "" {
+		args = append([]string{"--context", e.Context}, args...)
+	}
+	cmd := exec.CommandContext(ctx, "kubectl", args...)
+	if stdin != nil {
+		cmd.Stdin = bytes.NewReader(stdin)
+	}
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", errors.Wrapf(err, "kubectl %v: %s", args, out)
+	}
+	return string(out), nil
+}
@@ -0,0 +1,65 @@
+// Copyright 2023 Upbound Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package executor applies a generated Configuration migration plan against
+// a live cluster, one ordered step at a time.
+package executor
+
+import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/sergenyalcin/extensions-migration/pkg/converter/configuration"
+)
+
+// Plan is the ordered, serializable output of a Configuration migration: one
+// StepPlan per step of configuration.MigrationSteps(), holding everything
+// that step needs to apply against the cluster. See configuration.Step for
+// why the order matters; ValidateStepOrder enforces that the Steps follow
+// that order, but it does not require every step to be present.
+type Plan struct {
+	Steps []StepPlan `json:"steps"`
+}
+
+// StepPlan holds the work for a single configuration.Step.
+type StepPlan struct {
+	// Step identifies which phase of the migration this StepPlan performs.
+	Step configuration.Step `json:"step"`
+	// Patches are JSON merge patches applied before Apply, e.g. the
+	// Configuration's skipDependencyResolution toggle or the Lock's
+	// stripped package list.
+	Patches []Patch `json:"patches,omitempty"`
+	// Apply holds manifests to kubectl apply for this step, e.g. a new
+	// service-scoped Provider or the repackaged Configuration.pkg.
+	Apply []unstructured.Unstructured `json:"apply,omitempty"`
+	// WaitFor, if set, is polled until healthy and installed before the
+	// step is considered complete, e.g. a new Provider's ProviderRevision.
+	WaitFor []ObjectRef `json:"waitFor,omitempty"`
+	// Delete holds references to objects to delete once nothing refers to
+	// them any longer, e.g. the monolithic Provider.
+	Delete []ObjectRef `json:"delete,omitempty"`
+}
+
+// Patch is a JSON merge patch to apply to a single object.
+type Patch struct {
+	Ref   ObjectRef `json:"ref"`
+	Patch string    `json:"patch"`
+}
+
+// ObjectRef is a minimal reference to a cluster object.
+type ObjectRef struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Name       string `json:"name"`
+	Namespace  string `json:"namespace,omitempty"`
+}